@@ -1,17 +1,97 @@
 package codegate
 
 import (
+	"bytes"
+	"encoding/json"
+	"flag"
 	"fmt"
+	"hash/fnv"
+	"log"
+	"net/http"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 )
 
+// Stage describes the maturity of a code gate, modeled on the feature-gate
+// maturity stages used by Kubernetes: Alpha gates require explicit opt-in,
+// Beta and GA gates are on by default, and Deprecated gates are on by
+// default but call out every use so callers can plan their removal.
+type Stage int
+
+const (
+	// Alpha gates are off by default. They must be explicitly enabled via the
+	// EnableEnvVarPrefix environment variable, or a staged true value, before
+	// the gated code runs.
+	Alpha Stage = iota
+	// Beta gates are on by default and may be disabled via the EnvVarPrefix
+	// environment variable. This is the behavior New has always provided.
+	Beta
+	// GA gates are on by default. Disabling a GA gate is still supported but
+	// is logged as a warning since the behavior it guards is considered
+	// stable.
+	GA
+	// Deprecated gates are on by default, but every call to Enabled that
+	// reports the gate as enabled logs a deprecation warning. Like Beta and GA gates, they can still be forced
+	// off via the EnvVarPrefix environment variable.
+	Deprecated
+	// Rollout identifies a percentage-based RolloutGate in a GateEvent or
+	// GateInfo. It is not a valid Spec.Stage for New or NewWithSpec; it is
+	// only ever set by the registry itself, for gates created via
+	// NewRollout.
+	Rollout
+)
+
+// String returns the human-readable name of the stage.
+func (stage Stage) String() string {
+	switch stage {
+	case Alpha:
+		return "Alpha"
+	case Beta:
+		return "Beta"
+	case GA:
+		return "GA"
+	case Deprecated:
+		return "Deprecated"
+	case Rollout:
+		return "Rollout"
+	default:
+		return "Unknown"
+	}
+}
+
 // Gate is a code gate, allowing code to be selectively enabled or disabled.
+// Gate is a small value type; every copy of a Gate shares the same
+// underlying state, so a Gate may be freely passed around and stored in
+// static initializers.
 type Gate struct {
-	name    string
-	enabled bool
+	name  string
+	stage Stage
+	since string
+	state *gateState
+}
+
+// gateState holds the mutable, shared part of a Gate. It is accessed
+// through an atomic.Bool rather than protected by a Registry's mutex so
+// that Enabled stays cheap to call on every gated code path.
+type gateState struct {
+	enabled atomic.Bool
+}
+
+// Spec describes the lifecycle configuration for a gate created with
+// NewWithSpec. The zero value of Stage is Alpha, so a zero Spec creates an
+// Alpha gate; New passes Spec{Stage: Beta} explicitly.
+type Spec struct {
+	// Stage controls the gate's default state and how enabling/disabling it
+	// is surfaced. The zero value is Alpha.
+	Stage Stage
+	// Since records when the gate was introduced (a version, a date, or any
+	// other value meaningful for auditing rollout history). Optional.
+	Since string
 }
 
 // EnvVarPrefix is the prefix for environment variables used to disable
@@ -25,6 +105,11 @@ type Gate struct {
 // initializers.
 var (
 	EnvVarPrefix = "DISABLE_CODE_"
+
+	// EnableEnvVarPrefix is the prefix for environment variables used to
+	// enable code gates that are off by default (Alpha gates). It is subject
+	// to the same restrictions as EnvVarPrefix.
+	EnableEnvVarPrefix = "ENABLE_CODE_"
 )
 
 const (
@@ -33,16 +118,111 @@ const (
 	nameMaxLength = 100
 )
 
-var (
-	// gate names must be valid environment variable names
-	validName     = regexp.MustCompile("^[A-Za-z][A-Za-z0-9_]*$")
-	usedNames     = map[string]struct{}{}
-	disabledGates []string
-	gateLock      sync.Mutex
+// gate names must be valid environment variable names
+var validName = regexp.MustCompile("^[A-Za-z][A-Za-z0-9_]*$")
+
+// registeredGate is everything a Registry tracks about a gate beyond the
+// small, copyable Gate value handed back to callers.
+type registeredGate struct {
+	name    string
+	stage   Stage
+	since   string
+	source  string // "env", "staged", or "default"
+	state   *gateState
+	history []ToggleEvent
+}
+
+// ToggleEvent records a single change to a gate's enabled state, used for
+// the history returned by Handler.
+type ToggleEvent struct {
+	Enabled bool   `json:"enabled"`
+	Source  string `json:"source"`
+}
+
+// rolloutState holds the mutable, shared part of a RolloutGate.
+type rolloutState struct {
+	percent atomic.Int32
+}
+
+// registeredRollout is everything a Registry tracks about a rollout gate.
+type registeredRollout struct {
+	name   string
+	source string // "env", "staged", or "default"
+	state  *rolloutState
+}
+
+// GateEventKind distinguishes the kinds of lifecycle events delivered to
+// Subscribe callbacks.
+type GateEventKind int
+
+const (
+	// GateCreated fires once, when a gate is first created via New,
+	// NewWithSpec, or NewRollout.
+	GateCreated GateEventKind = iota
+	// GateToggled fires whenever a gate's enabled state changes after
+	// creation, e.g. via OverrideForTesting.
+	GateToggled
 )
 
-// New creates a code gate. Code gate names must be globally unique and should
-// be defined in static initializers. For example,
+// GateEvent describes a single gate lifecycle event, delivered synchronously
+// to every func registered with Subscribe. Percent is only set when Stage is
+// Rollout, i.e. for events about a RolloutGate created via NewRollout.
+type GateEvent struct {
+	Kind    GateEventKind
+	Name    string
+	Stage   Stage
+	Enabled bool
+	Percent *int
+	Source  string
+}
+
+// GateInfo is the JSON-serializable snapshot of a single gate or rollout
+// gate returned by Handler. Percent is only set for rollout gates, in
+// which case Stage is "Rollout" and History is always empty.
+type GateInfo struct {
+	Name    string        `json:"name"`
+	Stage   string        `json:"stage"`
+	Since   string        `json:"since,omitempty"`
+	Enabled bool          `json:"enabled"`
+	Percent *int          `json:"percent,omitempty"`
+	Source  string        `json:"source"`
+	History []ToggleEvent `json:"history,omitempty"`
+}
+
+// Registry tracks every code gate created through it, along with toggle
+// history and subscribers for gate lifecycle events. New, NewWithSpec,
+// NewRollout, DisabledGates, EnabledGates, KnownGates, LoadFromString,
+// LoadFromFile, LoadFromFlags, Bind, OverrideForTesting, Subscribe, and
+// Handler are thin wrappers around a package-level default Registry;
+// application code only needs to construct its own Registry when it wants
+// gates fully isolated from the rest of the process (e.g. in tests of the
+// registry itself).
+type Registry struct {
+	mu                   sync.Mutex
+	gates                map[string]*registeredGate
+	rollouts             map[string]*registeredRollout
+	disabledGates        []string
+	stagedConfig         map[string]bool
+	stagedRolloutPercent map[string]int
+	subscribers          []func(GateEvent)
+}
+
+// NewRegistry returns an empty Registry.
+func NewRegistry() *Registry {
+	return &Registry{
+		gates:                map[string]*registeredGate{},
+		rollouts:             map[string]*registeredRollout{},
+		stagedConfig:         map[string]bool{},
+		stagedRolloutPercent: map[string]int{},
+	}
+}
+
+var defaultRegistry = NewRegistry()
+
+// New creates a Beta-stage code gate: enabled by default, and may be
+// disabled via the EnvVarPrefix environment variable. It is equivalent to
+// NewWithSpec(name, Spec{Stage: Beta}). Code gate names must be globally
+// unique and should be defined in static initializers. For example,
 //
 //	var gateRBACDeleteOrphanedGrants = codegate.New("RBACDeleteOrphanedGrants")
 //
@@ -51,23 +231,115 @@ var (
 // for each code domain (e.g., "RBAC" for RBAC related behaviors) is recommended.
 // New panics if the name is missing, invalid, or is a duplicate.
 func New(name string) Gate {
+	return defaultRegistry.New(name)
+}
+
+// New is the Registry method backing the package-level New.
+func (r *Registry) New(name string) Gate {
+	return r.NewWithSpec(name, Spec{Stage: Beta})
+}
+
+// NewWithSpec creates a code gate at the given lifecycle Stage. See Alpha,
+// Beta, GA, and Deprecated for how each stage affects the gate's default
+// state. Naming rules and panic conditions are the same as New.
+func NewWithSpec(name string, spec Spec) Gate {
+	return defaultRegistry.NewWithSpec(name, spec)
+}
+
+// NewWithSpec is the Registry method backing the package-level NewWithSpec.
+func (r *Registry) NewWithSpec(name string, spec Spec) Gate {
 	if !validName.MatchString(name) || len(name) > nameMaxLength {
 		panic(fmt.Errorf(`code gate name (%s) is invalid. Code gate names must begin with an alpha, contain only alphanumerics or underbars, and be no more than %d characters in length`,
 			name, nameMaxLength))
 	}
-	gateLock.Lock()
-	defer gateLock.Unlock()
-	if _, found := usedNames[name]; found {
+
+	r.mu.Lock()
+	if _, found := r.gates[name]; found {
+		r.mu.Unlock()
 		panic(fmt.Errorf(`code gate name (%s) is already in use. Code gate names must be unique`, name))
 	}
-	usedNames[name] = struct{}{}
-	_, disabled := os.LookupEnv(EnvVarPrefix + name)
-	if !disabled {
-		_, disabled = os.LookupEnv(envVarPrefix2 + name)
+	if _, found := r.rollouts[name]; found {
+		r.mu.Unlock()
+		panic(fmt.Errorf(`code gate name (%s) is already in use. Code gate names must be unique`, name))
 	}
-	return Gate{
+
+	// override, found, source is resolved in the order documented on
+	// LoadFromString: environment variables take precedence over any staged
+	// file/flag config, which in turn only applies if no environment
+	// variable is set.
+	override, found, source := r.resolveOverride(name)
+
+	var enabled bool
+	switch spec.Stage {
+	case Alpha:
+		// Alpha gates are off unless explicitly opted into.
+		enabled = found && override
+	case GA:
+		enabled = !found || override
+		if found && !override {
+			log.Printf("codegate: %s is a GA code gate; disabling it is not recommended", name)
+		}
+	default: // Beta, Deprecated
+		enabled = !found || override
+	}
+
+	state := &gateState{}
+	state.enabled.Store(enabled)
+	g := &registeredGate{
 		name:    name,
-		enabled: !disabled,
+		stage:   spec.Stage,
+		since:   spec.Since,
+		source:  source,
+		state:   state,
+		history: []ToggleEvent{{Enabled: enabled, Source: source}},
+	}
+	r.gates[name] = g
+	r.mu.Unlock()
+
+	r.notify(GateEvent{Kind: GateCreated, Name: name, Stage: spec.Stage, Enabled: enabled, Source: source})
+
+	return Gate{
+		name:  name,
+		stage: spec.Stage,
+		since: spec.Since,
+		state: state,
+	}
+}
+
+// resolveOverride reports the enabled/disabled override staged for name, if
+// any, checking the environment before any staged file/flag config, along
+// with a label identifying which source supplied it ("env", "staged", or
+// "default" if none did). The caller must hold r.mu.
+func (r *Registry) resolveOverride(name string) (override, found bool, source string) {
+	if _, ok := os.LookupEnv(EnvVarPrefix + name); ok {
+		return false, true, "env"
+	}
+	if _, ok := os.LookupEnv(envVarPrefix2 + name); ok {
+		return false, true, "env"
+	}
+	if _, ok := os.LookupEnv(EnableEnvVarPrefix + name); ok {
+		return true, true, "env"
+	}
+	if val, ok := r.stagedConfig[name]; ok {
+		return val, true, "staged"
+	}
+	if _, ok := r.stagedRolloutPercent[name]; ok {
+		// A staged integer percent only means something to a rollout gate
+		// (see resolveRolloutPercent); an ordinary bool gate of the same
+		// name never consults stagedRolloutPercent, so without this warning
+		// the staged value would be silently ignored.
+		log.Printf("codegate: %s has a staged rollout percent but was created as a plain gate; the staged value is ignored", name)
+	}
+	return false, false, "default"
+}
+
+// notify delivers event to every subscriber. The caller must not hold r.mu.
+func (r *Registry) notify(event GateEvent) {
+	r.mu.Lock()
+	subscribers := append([]func(GateEvent){}, r.subscribers...)
+	r.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(event)
 	}
 }
 
@@ -88,8 +360,14 @@ func New(name string) Gate {
 // process may implement code gates. This implementation may change in the
 // future. Runtime code outside this package should not have any dependencies on
 // the environment variable implementation.
+//
+// Alpha gates report false unless they have been explicitly enabled.
 func (gate Gate) Enabled() bool {
-	return gate.enabled
+	enabled := gate.state.enabled.Load()
+	if gate.stage == Deprecated && enabled {
+		log.Printf("codegate: %s is deprecated and may be removed in a future release", gate.name)
+	}
+	return enabled
 }
 
 // Name returns the code gate name.
@@ -97,39 +375,537 @@ func (gate Gate) Name() string {
 	return gate.name
 }
 
+// Stage returns the lifecycle stage the gate was created with.
+func (gate Gate) Stage() Stage {
+	return gate.stage
+}
+
+// Since returns the Since value the gate was created with, if any.
+func (gate Gate) Since() string {
+	return gate.since
+}
+
 // String returns a string representation of the code gate and its state.
 func (gate Gate) String() string {
-	label := fmt.Sprintf("code gate %s", gate.name)
-	if gate.enabled {
+	label := fmt.Sprintf("code gate %s (%s)", gate.name, gate.stage)
+	if gate.state.enabled.Load() {
 		return label + " (enabled)"
 	}
 	return label + " (disabled)"
 }
 
+// RolloutEnvVarPrefix is the prefix for environment variables used to set a
+// rollout gate's percentage directly, e.g. CODE_ROLLOUT_MyGate=25. It is
+// subject to the same restrictions as EnvVarPrefix.
+var RolloutEnvVarPrefix = "CODE_ROLLOUT_"
+
+// RolloutGate is a percentage-based gradual rollout gate. Unlike Gate,
+// which is either on or off for the whole process, RolloutGate.EnabledFor
+// hashes a caller-supplied key (a tenant ID, user ID, request ID, ...) into
+// a stable bucket and reports whether that bucket falls under the gate's
+// current rollout percentage. The same key always lands in the same
+// bucket, so a given tenant/user/request consistently is or isn't in the
+// rollout as the percentage ramps from 0 to 100. Like Gate, every copy of
+// a RolloutGate shares the same underlying state.
+type RolloutGate struct {
+	name  string
+	state *rolloutState
+}
+
+// NewRollout creates a percentage-based gradual rollout gate. Unless
+// overridden, EnabledFor returns true for defaultPercent percent of keys.
+// Configuration is resolved in the same precedence order as New:
+// DISABLE_CODE_<name> forces the percentage to 0, ENABLE_CODE_<name> forces
+// it to 100, and RolloutEnvVarPrefix+<name> (CODE_ROLLOUT_<name> by
+// default) sets an explicit integer percentage between 0 and 100; any of
+// these may also be staged via LoadFromString/LoadFromFile/LoadFromFlags.
+// Naming rules and panic conditions are the same as New; NewRollout also
+// panics if defaultPercent is outside [0, 100].
+func NewRollout(name string, defaultPercent int) RolloutGate {
+	return defaultRegistry.NewRollout(name, defaultPercent)
+}
+
+// NewRollout is the Registry method backing the package-level NewRollout.
+func (r *Registry) NewRollout(name string, defaultPercent int) RolloutGate {
+	if !validName.MatchString(name) || len(name) > nameMaxLength {
+		panic(fmt.Errorf(`code gate name (%s) is invalid. Code gate names must begin with an alpha, contain only alphanumerics or underbars, and be no more than %d characters in length`,
+			name, nameMaxLength))
+	}
+	if defaultPercent < 0 || defaultPercent > 100 {
+		panic(fmt.Errorf("codegate: rollout gate (%s) default percent (%d) must be between 0 and 100", name, defaultPercent))
+	}
+
+	r.mu.Lock()
+	if _, found := r.gates[name]; found {
+		r.mu.Unlock()
+		panic(fmt.Errorf(`code gate name (%s) is already in use. Code gate names must be unique`, name))
+	}
+	if _, found := r.rollouts[name]; found {
+		r.mu.Unlock()
+		panic(fmt.Errorf(`code gate name (%s) is already in use. Code gate names must be unique`, name))
+	}
+
+	percent, source := r.resolveRolloutPercent(name, defaultPercent)
+
+	state := &rolloutState{}
+	state.percent.Store(int32(percent))
+	r.rollouts[name] = &registeredRollout{name: name, source: source, state: state}
+	r.mu.Unlock()
+
+	r.notify(GateEvent{Kind: GateCreated, Name: name, Stage: Rollout, Enabled: percent > 0, Percent: &percent, Source: source})
+
+	return RolloutGate{name: name, state: state}
+}
+
+// resolveRolloutPercent determines a rollout gate's percentage: it checks
+// DISABLE_CODE_<name> (forces 0), ENABLE_CODE_<name> (forces 100), and
+// RolloutEnvVarPrefix+<name> (an explicit 0-100 integer) in the environment
+// before consulting any staged file/flag config, falling back to
+// defaultPercent if nothing overrides it. The caller must hold r.mu.
+func (r *Registry) resolveRolloutPercent(name string, defaultPercent int) (percent int, source string) {
+	if _, ok := os.LookupEnv(EnvVarPrefix + name); ok {
+		return 0, "env"
+	}
+	if _, ok := os.LookupEnv(envVarPrefix2 + name); ok {
+		return 0, "env"
+	}
+	if _, ok := os.LookupEnv(EnableEnvVarPrefix + name); ok {
+		return 100, "env"
+	}
+	if raw, ok := os.LookupEnv(RolloutEnvVarPrefix + name); ok {
+		parsed, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil || parsed < 0 || parsed > 100 {
+			log.Printf("codegate: ignoring invalid %s%s=%q, must be an integer between 0 and 100", RolloutEnvVarPrefix, name, raw)
+		} else {
+			return parsed, "env"
+		}
+	}
+	if val, ok := r.stagedConfig[name]; ok {
+		if val {
+			return 100, "staged"
+		}
+		return 0, "staged"
+	}
+	if percent, ok := r.stagedRolloutPercent[name]; ok {
+		return percent, "staged"
+	}
+	return defaultPercent, "default"
+}
+
+// Name returns the rollout gate's name.
+func (gate RolloutGate) Name() string {
+	return gate.name
+}
+
+// Percent returns the rollout gate's current rollout percentage (0-100).
+func (gate RolloutGate) Percent() int {
+	return int(gate.state.percent.Load())
+}
+
+// EnabledFor hashes key into a stable bucket using FNV-64a of
+// name+"|"+key and reports whether that bucket falls under the gate's
+// current rollout percentage. Hashing name into the key means the same key
+// can land in different buckets for different rollout gates.
+func (gate RolloutGate) EnabledFor(key string) bool {
+	percent := gate.state.percent.Load()
+	if percent <= 0 {
+		return false
+	}
+	if percent >= 100 {
+		return true
+	}
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(gate.name + "|" + key))
+	bucket := h.Sum64() % 10000
+	return bucket < uint64(percent)*100
+}
+
+// EnabledForAny reports whether EnabledFor returns true for any of keys,
+// e.g. when a request can be identified by either a tenant ID or a user ID
+// and either one being in the rollout should enable the behavior.
+func (gate RolloutGate) EnabledForAny(keys ...string) bool {
+	for _, key := range keys {
+		if gate.EnabledFor(key) {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns a string representation of the rollout gate and its
+// current percentage.
+func (gate RolloutGate) String() string {
+	return fmt.Sprintf("rollout gate %s (%d%%)", gate.name, gate.state.percent.Load())
+}
+
 // DisabledGates returns the names of all currently disabled code gates. The
 // list is loaded from the environment variables and includes all variables
 // prefixed with the code gate prefix regardless of whether a gate has been
 // created for that name.
 func DisabledGates() []string {
-	gateLock.Lock()
-	defer gateLock.Unlock()
-	if disabledGates == nil {
-		disabledGates = []string{}
+	return defaultRegistry.DisabledGates()
+}
+
+// DisabledGates is the Registry method backing the package-level
+// DisabledGates.
+func (r *Registry) DisabledGates() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.disabledGates == nil {
+		r.disabledGates = []string{}
 		// Get all disabled code gates from the environment variables.
 		for _, env := range os.Environ() {
 			envName, _, _ := strings.Cut(env, "=")
 			if strings.HasPrefix(envName, EnvVarPrefix) {
-				disabledGates = append(disabledGates, strings.TrimPrefix(envName, EnvVarPrefix))
+				r.disabledGates = append(r.disabledGates, strings.TrimPrefix(envName, EnvVarPrefix))
 			} else if strings.HasPrefix(envName, envVarPrefix2) {
-				disabledGates = append(disabledGates, strings.TrimPrefix(envName, envVarPrefix2))
+				r.disabledGates = append(r.disabledGates, strings.TrimPrefix(envName, envVarPrefix2))
 			}
 		}
 	}
-	return disabledGates
+	return r.disabledGates
+}
+
+// EnabledGates returns the names of all code gates explicitly enabled via the
+// EnableEnvVarPrefix environment variable, regardless of whether a gate has
+// been created for that name. This is primarily useful for auditing which
+// Alpha gates have been opted into.
+func EnabledGates() []string {
+	return defaultRegistry.EnabledGates()
+}
+
+// EnabledGates is the Registry method backing the package-level
+// EnabledGates.
+func (r *Registry) EnabledGates() []string {
+	enabledGates := []string{}
+	for _, env := range os.Environ() {
+		envName, _, _ := strings.Cut(env, "=")
+		if strings.HasPrefix(envName, EnableEnvVarPrefix) {
+			enabledGates = append(enabledGates, strings.TrimPrefix(envName, EnableEnvVarPrefix))
+		}
+	}
+	return enabledGates
+}
+
+// KnownGates returns the names of all code gates created so far via New,
+// NewWithSpec, or NewRollout, in no particular order.
+func KnownGates() []string {
+	return defaultRegistry.KnownGates()
+}
+
+// KnownGates is the Registry method backing the package-level KnownGates.
+func (r *Registry) KnownGates() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	names := make([]string, 0, len(r.gates)+len(r.rollouts))
+	for name := range r.gates {
+		names = append(names, name)
+	}
+	for name := range r.rollouts {
+		names = append(names, name)
+	}
+	return names
+}
+
+// LoadFromString stages gate overrides from a comma-separated list of
+// key=value pairs, e.g. "RBACDeleteOrphanedGrants=false,NewScheduler=true",
+// the same syntax used by Kubernetes' and AWS controller runtime's
+// --feature-gates flag. A value may also be an integer between 0 and 100,
+// e.g. "MyRollout=25", to stage a rollout gate's percentage. Staged values
+// are consulted by New, NewWithSpec, and NewRollout for gates created
+// afterwards; they have no effect on gates that already exist, and an
+// environment variable for a gate always takes precedence over a staged
+// value for that gate. LoadFromString is typically called before any gates
+// in the affected packages are created, e.g. at the top of main.
+//
+// A staged integer percent only applies if the name is later created with
+// NewRollout; if it is instead created with New or NewWithSpec, the staged
+// value is ignored (a warning is logged) because plain gates only consult
+// staged bool values.
+func LoadFromString(s string) error {
+	return defaultRegistry.LoadFromString(s)
+}
+
+// LoadFromString is the Registry method backing the package-level
+// LoadFromString.
+func (r *Registry) LoadFromString(s string) error {
+	stagedBool := map[string]bool{}
+	stagedPercent := map[string]int{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("codegate: invalid feature-gates entry (%s), expected key=value", pair)
+		}
+		key = strings.TrimSpace(key)
+		value = strings.TrimSpace(value)
+
+		// Only the literal words true/false select the on/off form; this
+		// must be checked before strconv.Atoi, otherwise a staged rollout
+		// percent of "1" or "0" would be swallowed by strconv.ParseBool
+		// (which also accepts "1", "0", "t", "f", ...) and could never mean
+		// 1% or a disabled 0% rollout.
+		switch strings.ToLower(value) {
+		case "true":
+			stagedBool[key] = true
+			continue
+		case "false":
+			stagedBool[key] = false
+			continue
+		}
+		if percent, err := strconv.Atoi(value); err == nil {
+			if percent < 0 || percent > 100 {
+				return fmt.Errorf("codegate: invalid rollout percent for %s: %d must be between 0 and 100", key, percent)
+			}
+			stagedPercent[key] = percent
+			continue
+		}
+		return fmt.Errorf("codegate: invalid value for gate %s: %q is neither true/false nor a 0-100 percent", key, value)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, enabled := range stagedBool {
+		r.stagedConfig[key] = enabled
+	}
+	for key, percent := range stagedPercent {
+		r.stagedRolloutPercent[key] = percent
+	}
+	return nil
+}
+
+// LoadFromFile stages gate overrides read from a config file. Files whose
+// content is a JSON object, e.g. {"RBACDeleteOrphanedGrants": false}, are
+// decoded as such (a YAML flow mapping is accepted only if it is also valid
+// JSON, i.e. with quoted keys); anything else is treated as line-oriented
+// key=value or block-style YAML key: value pairs (one per line, blank
+// lines and #-comments ignored) and staged using the same value syntax as
+// LoadFromString, including the same caveat that a staged integer percent
+// is ignored (with a logged warning) unless the name is created with
+// NewRollout.
+func LoadFromFile(path string) error {
+	return defaultRegistry.LoadFromFile(path)
+}
+
+// LoadFromFile is the Registry method backing the package-level
+// LoadFromFile.
+func (r *Registry) LoadFromFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("codegate: reading feature-gate file (%s): %w", path, err)
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		staged := map[string]any{}
+		if err := json.Unmarshal(trimmed, &staged); err != nil {
+			return fmt.Errorf("codegate: parsing feature-gate file (%s): %w", path, err)
+		}
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		for key, value := range staged {
+			switch value := value.(type) {
+			case bool:
+				r.stagedConfig[key] = value
+			case float64:
+				if value < 0 || value > 100 {
+					return fmt.Errorf("codegate: invalid rollout percent for %s in %s: %v must be between 0 and 100", key, path, value)
+				}
+				r.stagedRolloutPercent[key] = int(value)
+			default:
+				return fmt.Errorf("codegate: invalid value for %s in %s: %v is neither a bool nor a 0-100 percent", key, path, value)
+			}
+		}
+		return nil
+	}
+
+	var pairs []string
+	for _, line := range strings.Split(string(trimmed), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if key, value, ok := strings.Cut(line, "="); ok {
+			pairs = append(pairs, key+"="+value)
+			continue
+		}
+		if key, value, ok := strings.Cut(line, ":"); ok {
+			pairs = append(pairs, key+"="+value)
+			continue
+		}
+		pairs = append(pairs, line)
+	}
+	return r.LoadFromString(strings.Join(pairs, ","))
+}
+
+// LoadFromFlags registers a --feature-gates flag on flagSet, accepting the
+// same key=value,key=value syntax as LoadFromString. It must be called
+// before flagSet.Parse, and flagSet.Parse must run before creating any gate
+// the flag is meant to affect.
+func LoadFromFlags(flagSet *flag.FlagSet) {
+	defaultRegistry.LoadFromFlags(flagSet)
+}
+
+// LoadFromFlags is the Registry method backing the package-level
+// LoadFromFlags.
+func (r *Registry) LoadFromFlags(flagSet *flag.FlagSet) {
+	flagSet.Var(r.Bind(), "feature-gates",
+		"Comma-separated key=value pairs, e.g. RBACDeleteOrphanedGrants=false,NewScheduler=true")
+}
+
+// FlagValue stages gate overrides via LoadFromString each time Set is
+// called. It implements both the standard library's flag.Value interface
+// and spf13/pflag's pflag.Value interface (which additionally requires
+// Type), so it can be registered with either flag package.
+type FlagValue struct {
+	registry *Registry
+}
+
+// String implements flag.Value and pflag.Value.
+func (FlagValue) String() string {
+	return ""
+}
+
+// Set implements flag.Value and pflag.Value by staging value with
+// LoadFromString.
+func (f FlagValue) Set(value string) error {
+	return f.registry.LoadFromString(value)
+}
+
+// Type implements pflag.Value.
+func (FlagValue) Type() string {
+	return "feature-gates"
+}
+
+// Bind returns a flag.Value (and pflag.Value) that stages gate overrides
+// via LoadFromString whenever Set is called, for use with either the
+// standard library flag package or spf13/pflag:
+//
+//	flagSet.Var(codegate.Bind(), "feature-gates", "comma-separated key=value pairs")
+//	pflagSet.Var(codegate.Bind(), "feature-gates", "comma-separated key=value pairs")
+func Bind() FlagValue {
+	return defaultRegistry.Bind()
+}
+
+// Bind is the Registry method backing the package-level Bind.
+func (r *Registry) Bind() FlagValue {
+	return FlagValue{registry: r}
+}
+
+// OverrideForTesting forcibly sets the named gate's enabled state and
+// returns a function that restores whatever state the gate was in before
+// the override. It panics if no gate with that name has been created.
+//
+// OverrideForTesting is exported so the codegate/codegatetest package can be
+// built on top of it; application code should use codegatetest.SetEnabled
+// or codegatetest.Override instead of calling this directly.
+func OverrideForTesting(name string, enabled bool) (restore func()) {
+	return defaultRegistry.OverrideForTesting(name, enabled)
+}
+
+// OverrideForTesting is the Registry method backing the package-level
+// OverrideForTesting.
+func (r *Registry) OverrideForTesting(name string, enabled bool) (restore func()) {
+	r.mu.Lock()
+	g, found := r.gates[name]
+	if !found {
+		r.mu.Unlock()
+		panic(fmt.Errorf("codegate: no such code gate (%s)", name))
+	}
+	previous := g.state.enabled.Swap(enabled)
+	g.history = append(g.history, ToggleEvent{Enabled: enabled, Source: "test"})
+	r.mu.Unlock()
+
+	r.notify(GateEvent{Kind: GateToggled, Name: name, Stage: g.stage, Enabled: enabled, Source: "test"})
+
+	return func() {
+		r.mu.Lock()
+		g.state.enabled.Store(previous)
+		g.history = append(g.history, ToggleEvent{Enabled: previous, Source: "test"})
+		r.mu.Unlock()
+		r.notify(GateEvent{Kind: GateToggled, Name: name, Stage: g.stage, Enabled: previous, Source: "test"})
+	}
+}
+
+// Subscribe registers fn to be called whenever a gate is created, and
+// whenever a gate's enabled state subsequently changes (e.g. via
+// OverrideForTesting). fn is called synchronously on the goroutine that
+// triggered the event, so it should return quickly and must not call back
+// into the package.
+func Subscribe(fn func(GateEvent)) {
+	defaultRegistry.Subscribe(fn)
+}
+
+// Subscribe is the Registry method backing the package-level Subscribe.
+func (r *Registry) Subscribe(fn func(GateEvent)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.subscribers = append(r.subscribers, fn)
+}
+
+// Handler returns an http.Handler that serves a JSON array describing every
+// known gate: its name, stage, current state, the source that determined
+// that state (env, staged, or default), and its toggle history. It is meant
+// to be mounted on an internal debug/ops mux, e.g.
+//
+//	mux.Handle("/debug/codegate", codegate.Handler())
+func Handler() http.Handler {
+	return defaultRegistry.Handler()
+}
+
+// Handler is the Registry method backing the package-level Handler.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		infos := r.snapshot()
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(infos); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// snapshot returns a JSON-serializable, name-sorted view of every gate and
+// rollout gate known to the registry.
+func (r *Registry) snapshot() []GateInfo {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	infos := make([]GateInfo, 0, len(r.gates)+len(r.rollouts))
+	for _, g := range r.gates {
+		infos = append(infos, GateInfo{
+			Name:    g.name,
+			Stage:   g.stage.String(),
+			Since:   g.since,
+			Enabled: g.state.enabled.Load(),
+			Source:  g.source,
+			History: append([]ToggleEvent{}, g.history...),
+		})
+	}
+	for _, g := range r.rollouts {
+		percent := int(g.state.percent.Load())
+		infos = append(infos, GateInfo{
+			Name:    g.name,
+			Stage:   Rollout.String(),
+			Enabled: percent > 0,
+			Percent: &percent,
+			Source:  g.source,
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
 }
 
 func resetDisabledGates() {
-	gateLock.Lock()
-	defer gateLock.Unlock()
-	disabledGates = nil
+	defaultRegistry.mu.Lock()
+	defer defaultRegistry.mu.Unlock()
+	defaultRegistry.disabledGates = nil
+}
+
+func resetStagedConfig() {
+	defaultRegistry.mu.Lock()
+	defer defaultRegistry.mu.Unlock()
+	defaultRegistry.stagedConfig = map[string]bool{}
+	defaultRegistry.stagedRolloutPercent = map[string]int{}
 }
@@ -1,6 +1,13 @@
 package codegate
 
 import (
+	"encoding/json"
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
 	"testing"
 
@@ -111,3 +118,319 @@ func TestResetDisabledGates(t *testing.T) {
 	resetDisabledGates()
 	require.Contains(t, DisabledGates(), "Foo", "DisabledGates(true) should refresh the disabled gates")
 }
+
+func TestAlphaGateOffByDefault(t *testing.T) {
+	gate := NewWithSpec("AlphaGate", Spec{Stage: Alpha})
+	require.False(t, gate.Enabled(), "Alpha gates must be off until explicitly enabled")
+}
+
+func TestAlphaGateEnabledViaEnv(t *testing.T) {
+	t.Setenv("ENABLE_CODE_AlphaGateEnabled", "enabled")
+	gate := NewWithSpec("AlphaGateEnabled", Spec{Stage: Alpha})
+	require.True(t, gate.Enabled(), "Alpha gates should turn on once ENABLE_CODE_ is set")
+}
+
+func TestAlphaGateDisableWinsOverEnable(t *testing.T) {
+	t.Setenv("ENABLE_CODE_AlphaGateBoth", "enabled")
+	t.Setenv("DISABLE_CODE_AlphaGateBoth", "disabled")
+	gate := NewWithSpec("AlphaGateBoth", Spec{Stage: Alpha})
+	require.False(t, gate.Enabled(), "DISABLE_CODE_ should take precedence over ENABLE_CODE_ for Alpha gates")
+}
+
+func TestBetaGateOnByDefaultAndDisableable(t *testing.T) {
+	gate := NewWithSpec("BetaGate", Spec{Stage: Beta})
+	require.True(t, gate.Enabled())
+
+	t.Setenv("DISABLE_CODE_BetaGateDisabled", "disabled")
+	disabledGate := NewWithSpec("BetaGateDisabled", Spec{Stage: Beta})
+	require.False(t, disabledGate.Enabled())
+}
+
+func TestGAGateOnByDefaultAndDisableable(t *testing.T) {
+	gate := NewWithSpec("GAGate", Spec{Stage: GA})
+	require.True(t, gate.Enabled())
+
+	t.Setenv("DISABLE_CODE_GAGateDisabled", "disabled")
+	disabledGate := NewWithSpec("GAGateDisabled", Spec{Stage: GA})
+	require.False(t, disabledGate.Enabled(), "GA gates may still be disabled, just with a logged warning")
+}
+
+func TestDeprecatedGateOnByDefaultAndDisableable(t *testing.T) {
+	gate := NewWithSpec("DeprecatedGate", Spec{Stage: Deprecated, Since: "v1.2.3"})
+	require.True(t, gate.Enabled())
+	require.Equal(t, "v1.2.3", gate.Since())
+
+	t.Setenv("DISABLE_CODE_DeprecatedGateDisabled", "disabled")
+	disabledGate := NewWithSpec("DeprecatedGateDisabled", Spec{Stage: Deprecated})
+	require.False(t, disabledGate.Enabled())
+}
+
+func TestStageAndSinceAccessors(t *testing.T) {
+	gate := NewWithSpec("StageAccessorGate", Spec{Stage: GA, Since: "2024-01-01"})
+	require.Equal(t, GA, gate.Stage())
+	require.Equal(t, "2024-01-01", gate.Since())
+
+	plain := New("StageAccessorBetaGate")
+	require.Equal(t, Beta, plain.Stage())
+	require.Empty(t, plain.Since())
+}
+
+func TestEnabledGates(t *testing.T) {
+	t.Setenv("ENABLE_CODE_EnabledGatesAlpha", "enabled")
+	require.Contains(t, EnabledGates(), "EnabledGatesAlpha")
+	require.NotContains(t, EnabledGates(), "EnabledGatesOther")
+}
+
+func TestKnownGates(t *testing.T) {
+	_ = New("KnownGatesFoo")
+	require.Contains(t, KnownGates(), "KnownGatesFoo")
+}
+
+func TestLoadFromString(t *testing.T) {
+	t.Cleanup(resetStagedConfig)
+
+	require.NoError(t, LoadFromString("StagedGateA=false, StagedGateB=true"))
+
+	require.False(t, New("StagedGateA").Enabled())
+	require.True(t, New("StagedGateB").Enabled())
+}
+
+func TestLoadFromStringEnvTakesPrecedence(t *testing.T) {
+	t.Cleanup(resetStagedConfig)
+	t.Setenv("DISABLE_CODE_StagedGateEnv", "disabled")
+
+	require.NoError(t, LoadFromString("StagedGateEnv=true"))
+
+	require.False(t, New("StagedGateEnv").Enabled(), "an environment variable should override staged config")
+}
+
+func TestLoadFromStringInvalid(t *testing.T) {
+	require.Error(t, LoadFromString("NotKeyValue"))
+	require.Error(t, LoadFromString("StagedGateBad=notabool"))
+}
+
+func TestLoadFromStringPercentNotConfusedWithBool(t *testing.T) {
+	t.Cleanup(resetStagedConfig)
+
+	require.NoError(t, LoadFromString("StagedRolloutOnePercent=1, StagedRolloutZeroPercent=0"))
+
+	require.Equal(t, 1, NewRollout("StagedRolloutOnePercent", 50).Percent(), "a staged value of 1 must mean 1%%, not true/100%%")
+	require.Equal(t, 0, NewRollout("StagedRolloutZeroPercent", 50).Percent())
+}
+
+func TestLoadFromStringPercentIgnoredByPlainGate(t *testing.T) {
+	t.Cleanup(resetStagedConfig)
+
+	require.NoError(t, LoadFromString("StagedPercentForPlainGate=0"))
+
+	// The staged value of 0 is a rollout percent, not a bool; since this
+	// name is created as a plain gate, it is never consulted and the gate
+	// falls back to its Beta default of enabled.
+	gate := New("StagedPercentForPlainGate")
+	require.True(t, gate.Enabled())
+}
+
+func TestLoadFromFileJSON(t *testing.T) {
+	t.Cleanup(resetStagedConfig)
+
+	path := filepath.Join(t.TempDir(), "feature-gates.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"StagedFileGateA": false, "StagedFileGateB": true}`), 0o600))
+
+	require.NoError(t, LoadFromFile(path))
+	require.False(t, New("StagedFileGateA").Enabled())
+	require.True(t, New("StagedFileGateB").Enabled())
+}
+
+func TestLoadFromFileKeyValue(t *testing.T) {
+	t.Cleanup(resetStagedConfig)
+
+	path := filepath.Join(t.TempDir(), "feature-gates.txt")
+	require.NoError(t, os.WriteFile(path, []byte("StagedFileGateC=false\nStagedFileGateD=true\n"), 0o600))
+
+	require.NoError(t, LoadFromFile(path))
+	require.False(t, New("StagedFileGateC").Enabled())
+	require.True(t, New("StagedFileGateD").Enabled())
+}
+
+func TestLoadFromFileYAMLBlockStyle(t *testing.T) {
+	t.Cleanup(resetStagedConfig)
+
+	path := filepath.Join(t.TempDir(), "feature-gates.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("# staged gates\nStagedYAMLGateE: false\nStagedYAMLGateF: true\n"), 0o600))
+
+	require.NoError(t, LoadFromFile(path))
+	require.False(t, New("StagedYAMLGateE").Enabled())
+	require.True(t, New("StagedYAMLGateF").Enabled())
+}
+
+func TestLoadFromFlags(t *testing.T) {
+	t.Cleanup(resetStagedConfig)
+
+	flagSet := flag.NewFlagSet("test", flag.ContinueOnError)
+	LoadFromFlags(flagSet)
+	require.NoError(t, flagSet.Parse([]string{"-feature-gates=StagedFlagGateA=false,StagedFlagGateB=true"}))
+
+	require.False(t, New("StagedFlagGateA").Enabled())
+	require.True(t, New("StagedFlagGateB").Enabled())
+}
+
+func TestHandler(t *testing.T) {
+	t.Setenv("DISABLE_CODE_HandlerGate", "disabled")
+	_ = New("HandlerGate")
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/codegate", nil)
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var gates []GateInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gates))
+
+	var found *GateInfo
+	for i := range gates {
+		if gates[i].Name == "HandlerGate" {
+			found = &gates[i]
+		}
+	}
+	require.NotNil(t, found, "Handler should report HandlerGate")
+	require.False(t, found.Enabled)
+	require.Equal(t, "env", found.Source)
+	require.Equal(t, "Beta", found.Stage)
+	require.NotEmpty(t, found.History)
+}
+
+func TestHandlerIncludesRolloutGates(t *testing.T) {
+	_ = NewRollout("HandlerRolloutGate", 40)
+
+	req := httptest.NewRequest(http.MethodGet, "/debug/codegate", nil)
+	w := httptest.NewRecorder()
+	Handler().ServeHTTP(w, req)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var gates []GateInfo
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &gates))
+
+	var found *GateInfo
+	for i := range gates {
+		if gates[i].Name == "HandlerRolloutGate" {
+			found = &gates[i]
+		}
+	}
+	require.NotNil(t, found, "Handler should report HandlerRolloutGate")
+	require.Equal(t, "Rollout", found.Stage)
+	require.True(t, found.Enabled)
+	require.NotNil(t, found.Percent)
+	require.Equal(t, 40, *found.Percent)
+	require.Equal(t, "default", found.Source)
+}
+
+func TestSubscribe(t *testing.T) {
+	var events []GateEvent
+	Subscribe(func(event GateEvent) {
+		events = append(events, event)
+	})
+
+	gate := New("SubscribeGate")
+	require.Len(t, events, 1)
+	require.Equal(t, GateCreated, events[0].Kind)
+	require.Equal(t, "SubscribeGate", events[0].Name)
+	require.True(t, events[0].Enabled)
+
+	restore := OverrideForTesting(gate.Name(), false)
+	require.Len(t, events, 2)
+	require.Equal(t, GateToggled, events[1].Kind)
+	require.False(t, events[1].Enabled)
+
+	restore()
+	require.Len(t, events, 3)
+	require.True(t, events[2].Enabled)
+}
+
+func TestSubscribeRolloutGateCreated(t *testing.T) {
+	var events []GateEvent
+	Subscribe(func(event GateEvent) {
+		events = append(events, event)
+	})
+
+	_ = NewRollout("SubscribeRolloutGate", 30)
+	require.Len(t, events, 1)
+	require.Equal(t, GateCreated, events[0].Kind)
+	require.Equal(t, "SubscribeRolloutGate", events[0].Name)
+	require.Equal(t, Rollout, events[0].Stage, "a rollout gate's creation event must not be mislabeled Alpha")
+	require.True(t, events[0].Enabled)
+	require.NotNil(t, events[0].Percent)
+	require.Equal(t, 30, *events[0].Percent)
+}
+
+func TestRolloutDefaultPercent(t *testing.T) {
+	gate := NewRollout("RolloutDefault", 0)
+	require.Equal(t, 0, gate.Percent())
+	require.False(t, gate.EnabledFor("any-key"))
+
+	gateFull := NewRollout("RolloutDefaultFull", 100)
+	require.Equal(t, 100, gateFull.Percent())
+	require.True(t, gateFull.EnabledFor("any-key"))
+}
+
+func TestRolloutDisableEnableViaEnv(t *testing.T) {
+	t.Setenv("DISABLE_CODE_RolloutDisabled", "disabled")
+	gateDisabled := NewRollout("RolloutDisabled", 50)
+	require.Equal(t, 0, gateDisabled.Percent())
+
+	t.Setenv("ENABLE_CODE_RolloutEnabled", "enabled")
+	gateEnabled := NewRollout("RolloutEnabled", 0)
+	require.Equal(t, 100, gateEnabled.Percent())
+}
+
+func TestRolloutPercentEnvVar(t *testing.T) {
+	t.Setenv("CODE_ROLLOUT_RolloutPercentGate", "25")
+	gate := NewRollout("RolloutPercentGate", 0)
+	require.Equal(t, 25, gate.Percent())
+}
+
+func TestRolloutEnabledForIsStableAndApproximatesPercent(t *testing.T) {
+	gate := NewRollout("RolloutDistribution", 30)
+
+	enabled := 0
+	const total = 2000
+	for i := 0; i < total; i++ {
+		key := strconv.Itoa(i)
+		first := gate.EnabledFor(key)
+		require.Equal(t, first, gate.EnabledFor(key), "EnabledFor must be stable for a given key")
+		if first {
+			enabled++
+		}
+	}
+
+	got := float64(enabled) / total * 100
+	require.InDelta(t, 30, got, 5, "rollout percentage should be roughly 30%% across many keys")
+}
+
+func TestRolloutEnabledForAny(t *testing.T) {
+	gateOff := NewRollout("RolloutAnyOff", 0)
+	require.False(t, gateOff.EnabledForAny("a", "b", "c"))
+
+	gateOn := NewRollout("RolloutAnyOn", 100)
+	require.True(t, gateOn.EnabledForAny("a", "b", "c"))
+}
+
+func TestRolloutStagedPercent(t *testing.T) {
+	t.Cleanup(resetStagedConfig)
+
+	require.NoError(t, LoadFromString("StagedRolloutGate=40"))
+	gate := NewRollout("StagedRolloutGate", 0)
+	require.Equal(t, 40, gate.Percent())
+}
+
+func TestRolloutInvalidDefaultPercentPanics(t *testing.T) {
+	require.Panics(t, func() { NewRollout("RolloutInvalid", -1) })
+	require.Panics(t, func() { NewRollout("RolloutInvalid2", 101) })
+}
+
+func TestRolloutAndGateNamesShareNamespace(t *testing.T) {
+	_ = NewRollout("SharedNamespaceRollout", 50)
+	require.Panics(t, func() { New("SharedNamespaceRollout") }, "a rollout gate name should not be reusable by New")
+
+	_ = New("SharedNamespaceGate")
+	require.Panics(t, func() { NewRollout("SharedNamespaceGate", 50) }, "a gate name should not be reusable by NewRollout")
+}
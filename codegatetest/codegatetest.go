@@ -0,0 +1,33 @@
+// Package codegatetest provides test-only helpers for overriding code gate
+// state. Gates normally read their state once, from the environment, when
+// New or NewWithSpec is called, which makes it awkward to exercise both
+// branches of a gated code path in a single test. SetEnabled and Override
+// flip a gate's state for the duration of a test and restore it automatically
+// when the test completes.
+package codegatetest
+
+import (
+	"testing"
+
+	"github.com/singlestore-labs/codegate"
+)
+
+// SetEnabled forces gate to the given enabled state for the duration of t,
+// restoring its previous state via t.Cleanup. For example:
+//
+//	codegatetest.SetEnabled(t, gateRBACDeleteOrphanedGrants, false)
+func SetEnabled(t *testing.T, gate codegate.Gate, enabled bool) {
+	t.Helper()
+	Override(t, gate.Name(), enabled)
+}
+
+// Override forces the named code gate to the given enabled state for the
+// duration of t, restoring its previous state via t.Cleanup. It is
+// equivalent to SetEnabled but does not require a handle to the Gate value,
+// which is convenient when the gate variable isn't exported by its package.
+// Override panics if no gate with that name has been created.
+func Override(t *testing.T, name string, enabled bool) {
+	t.Helper()
+	restore := codegate.OverrideForTesting(name, enabled)
+	t.Cleanup(restore)
+}
@@ -0,0 +1,41 @@
+package codegatetest_test
+
+import (
+	"testing"
+
+	"github.com/singlestore-labs/codegate"
+	"github.com/singlestore-labs/codegate/codegatetest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSetEnabled(t *testing.T) {
+	gate := codegate.New("CodegatetestSetEnabled")
+	require.True(t, gate.Enabled())
+
+	t.Run("override", func(t *testing.T) {
+		codegatetest.SetEnabled(t, gate, false)
+		require.False(t, gate.Enabled())
+	})
+
+	// the outer test's copy of the gate is restored once the subtest
+	// completes, proving Gate shares its state across copies.
+	require.True(t, gate.Enabled())
+}
+
+func TestOverrideByName(t *testing.T) {
+	gate := codegate.New("CodegatetestOverride")
+	require.True(t, gate.Enabled())
+
+	t.Run("override", func(t *testing.T) {
+		codegatetest.Override(t, "CodegatetestOverride", false)
+		require.False(t, gate.Enabled())
+	})
+
+	require.True(t, gate.Enabled())
+}
+
+func TestOverrideUnknownGatePanics(t *testing.T) {
+	require.Panics(t, func() {
+		codegatetest.Override(t, "NoSuchCodegatetestGate", true)
+	})
+}